@@ -0,0 +1,175 @@
+package main
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/spatial/r3"
+)
+
+// Quaternion is a unit quaternion (W + Xi + Yj + Zk) used for orientation,
+// avoiding the gimbal lock and gimbal-order dependence of Euler angles.
+type Quaternion struct {
+	W, X, Y, Z float64
+}
+
+// QuatFromAxisAngle builds the quaternion rotating by angle radians around
+// axis, which must be a unit vector.
+func QuatFromAxisAngle(axis r3.Vec, angle float64) Quaternion {
+	half := angle / 2
+	s := math.Sin(half)
+	return Quaternion{W: math.Cos(half), X: axis.X * s, Y: axis.Y * s, Z: axis.Z * s}
+}
+
+// Mul returns the Hamilton product q*o; applied to a vector it rotates by o
+// first, then by q.
+func (q Quaternion) Mul(o Quaternion) Quaternion {
+	return Quaternion{
+		W: q.W*o.W - q.X*o.X - q.Y*o.Y - q.Z*o.Z,
+		X: q.W*o.X + q.X*o.W + q.Y*o.Z - q.Z*o.Y,
+		Y: q.W*o.Y - q.X*o.Z + q.Y*o.W + q.Z*o.X,
+		Z: q.W*o.Z + q.X*o.Y - q.Y*o.X + q.Z*o.W,
+	}
+}
+
+func (q Quaternion) Conjugate() Quaternion {
+	return Quaternion{W: q.W, X: -q.X, Y: -q.Y, Z: -q.Z}
+}
+
+func (q Quaternion) Normalize() Quaternion {
+	l := math.Sqrt(q.W*q.W + q.X*q.X + q.Y*q.Y + q.Z*q.Z)
+	if l == 0 {
+		return Quaternion{W: 1}
+	}
+	return Quaternion{W: q.W / l, X: q.X / l, Y: q.Y / l, Z: q.Z / l}
+}
+
+// RotateVec rotates v by q using the sandwich product q*v*q⁻¹.
+func (q Quaternion) RotateVec(v r3.Vec) r3.Vec {
+	p := Quaternion{X: v.X, Y: v.Y, Z: v.Z}
+	res := q.Mul(p).Mul(q.Conjugate())
+	return r3.Vec{X: res.X, Y: res.Y, Z: res.Z}
+}
+
+// QuatLerp linearly interpolates and renormalizes; cheap, but not
+// constant-angular-velocity like Slerp.
+func QuatLerp(a, b Quaternion, t float64) Quaternion {
+	return Quaternion{
+		W: lerp(a.W, b.W, t),
+		X: lerp(a.X, b.X, t),
+		Y: lerp(a.Y, b.Y, t),
+		Z: lerp(a.Z, b.Z, t),
+	}.Normalize()
+}
+
+// QuatSlerp spherically interpolates a to b, falling back to Lerp when the
+// angle between them is small enough that the difference doesn't matter.
+func QuatSlerp(a, b Quaternion, t float64) Quaternion {
+	d := a.W*b.W + a.X*b.X + a.Y*b.Y + a.Z*b.Z
+	if d < 0 {
+		b = Quaternion{W: -b.W, X: -b.X, Y: -b.Y, Z: -b.Z}
+		d = -d
+	}
+	if d > 0.9995 {
+		return QuatLerp(a, b, t)
+	}
+	theta0 := math.Acos(clampF(d, -1, 1))
+	theta := theta0 * t
+	sinTheta0 := math.Sin(theta0)
+	s0 := math.Cos(theta) - d*math.Sin(theta)/sinTheta0
+	s1 := math.Sin(theta) / sinTheta0
+	return Quaternion{
+		W: s0*a.W + s1*b.W,
+		X: s0*a.X + s1*b.X,
+		Y: s0*a.Y + s1*b.Y,
+		Z: s0*a.Z + s1*b.Z,
+	}
+}
+
+func clampF(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+const maxCameraPitch = 85 * math.Pi / 180
+
+// Camera is a free-look camera whose orientation is a unit quaternion, so
+// repeated small rotations never gimbal-lock or misbehave under roll the
+// way accumulated Euler yaw/pitch/roll do.
+type Camera struct {
+	Pos         r3.Vec
+	orientation Quaternion
+}
+
+// NewCamera returns a camera at the origin facing -Z with no roll.
+func NewCamera() *Camera {
+	return &Camera{orientation: Quaternion{W: 1}}
+}
+
+// Rotate applies incremental pitch/yaw/roll (degrees) as small delta
+// quaternions composed onto the current orientation: yaw around world up,
+// pitch around the camera's local right, roll around its local forward.
+// The ±85° pitch clamp is enforced by decomposing the resulting
+// orientation back to a pitch angle only when it's out of range.
+func (c *Camera) Rotate(pitchDeg, yawDeg, rollDeg float64) {
+	yaw := QuatFromAxisAngle(r3.Vec{Y: 1}, yawDeg*math.Pi/180)
+	pitch := QuatFromAxisAngle(c.Right(), pitchDeg*math.Pi/180)
+	roll := QuatFromAxisAngle(c.Forward(), rollDeg*math.Pi/180)
+	c.orientation = yaw.Mul(pitch).Mul(roll).Mul(c.orientation).Normalize()
+	c.clampPitch()
+}
+
+func (c *Camera) clampPitch() {
+	forward := c.Forward()
+	pitch := math.Asin(clampF(forward.Y, -1, 1))
+	if pitch > maxCameraPitch {
+		delta := QuatFromAxisAngle(c.Right(), -(pitch - maxCameraPitch))
+		c.orientation = delta.Mul(c.orientation).Normalize()
+	} else if pitch < -maxCameraPitch {
+		delta := QuatFromAxisAngle(c.Right(), -(pitch + maxCameraPitch))
+		c.orientation = delta.Mul(c.orientation).Normalize()
+	}
+}
+
+// LookAt reorients the camera so Forward() points at target, leaving roll
+// around the new forward axis unchanged.
+func (c *Camera) LookAt(target r3.Vec) {
+	desired := normalize(r3.Sub(target, c.Pos))
+	current := c.Forward()
+	axis := r3.Cross(current, desired)
+	axisLen := math.Sqrt(dot(axis, axis))
+	cosAngle := clampF(dot(current, desired), -1, 1)
+	if axisLen < 1e-9 {
+		if cosAngle > 0 {
+			return
+		}
+		axis = c.Up()
+		axisLen = math.Sqrt(dot(axis, axis))
+	}
+	axis = r3.Scale(1/axisLen, axis)
+	delta := QuatFromAxisAngle(axis, math.Acos(cosAngle))
+	c.orientation = delta.Mul(c.orientation).Normalize()
+}
+
+func (c *Camera) Forward() r3.Vec { return c.orientation.RotateVec(r3.Vec{Z: -1}) }
+func (c *Camera) Right() r3.Vec   { return c.orientation.RotateVec(r3.Vec{X: 1}) }
+func (c *Camera) Up() r3.Vec      { return c.orientation.RotateVec(r3.Vec{Y: 1}) }
+
+// Matrix returns the world-to-camera rotation matrix in the same row
+// convention convert3DTo2D expects from Renderer3D.cachedRotationMatrix:
+// row i is the camera's local axis i (X, Y, Z) expressed in world space.
+func (c *Camera) Matrix() *mat.Dense {
+	rx := c.Right()
+	ry := c.Up()
+	rz := c.orientation.RotateVec(r3.Vec{Z: 1})
+	return mat.NewDense(3, 3, []float64{
+		rx.X, rx.Y, rx.Z,
+		ry.X, ry.Y, ry.Z,
+		rz.X, rz.Y, rz.Z,
+	})
+}
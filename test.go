@@ -1,10 +1,16 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"image"
 	"image/color"
 	"math"
+	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/faiface/pixel"
@@ -20,6 +26,17 @@ import (
 type RenderItem struct {
 	Points []r3.Vec
 	Color  color.Color
+
+	// Texture and UVs (parallel to Points) opt a polygon into textured
+	// scanline rasterization instead of a flat imdraw.Polygon fill.
+	Texture image.Image
+	UVs     []pixel.Vec
+}
+
+// PlacedBlock is a single user-placed cube: its grid-aligned position and color.
+type PlacedBlock struct {
+	Pos   r3.Vec
+	Color color.Color
 }
 
 type Renderer3D struct {
@@ -29,6 +46,245 @@ type Renderer3D struct {
 	screenw, screenh     float64
 	cachedRotationMatrix *mat.Dense
 	renderDistance       float64
+
+	// GridSize is the edge length of a placed block / ground tile.
+	GridSize float64
+	// PlacedBlocks tracks user-placed cubes so they can be serialized,
+	// raycast against, and re-rendered independently of the static ground.
+	PlacedBlocks []PlacedBlock
+	groundItems  []RenderItem
+	// occupancy maps a voxel grid cell to its index in PlacedBlocks, for
+	// O(1) overlap checks and DDA raycast hit-testing.
+	occupancy map[[3]int]int
+
+	// UseBSP opts into BSP-ordered polygon drawing instead of the
+	// average-distance painter's sort, fixing artifacts on intersecting
+	// or camera-straddling faces.
+	UseBSP      bool
+	bsp         *BSPTree
+	bspBuiltLen int
+
+	// frame is the shared scanline-rasterization scratch buffer for
+	// textured polygons. Each textured item clears and composites only
+	// its own screen-space bounding box (see rasterizeTextured), not the
+	// whole buffer, so a frame with many textured tiles stays cheap and
+	// textured draws can still interleave with flat polygons by depth.
+	frame *pixel.PictureData
+
+	// EnableCulling opts into frustum culling (via an octree over polygon
+	// AABBs) plus backface culling of filled quads.
+	EnableCulling bool
+	octree        *Octree
+	octreeLen     int
+	// VisibleCount/TotalCount report the last Tick's culling results.
+	VisibleCount int
+	TotalCount   int
+}
+
+// ensureOctree rebuilds the octree from Render3D's current polygons if it
+// hasn't been built yet or Render3D has grown/shrunk since the last build.
+func (r *Renderer3D) ensureOctree() {
+	if r.octree != nil && r.octreeLen == len(r.Render3D) {
+		return
+	}
+	r.octree = NewOctree(r.Render3D)
+	r.octreeLen = len(r.Render3D)
+}
+
+// ensureFrame (re)allocates the texture frame buffer to match the current
+// screen size.
+func (r *Renderer3D) ensureFrame() *pixel.PictureData {
+	w, h := int(r.screenw), int(r.screenh)
+	if r.frame == nil || int(r.frame.Rect.W()) != w || int(r.frame.Rect.H()) != h {
+		r.frame = pixel.MakePictureData(pixel.R(0, 0, float64(w), float64(h)))
+	}
+	return r.frame
+}
+
+// ensureBSP rebuilds the BSP tree from source's current polygons if it
+// hasn't been built yet or source has grown/shrunk since the last build.
+// source is whatever Tick is about to draw: all of Render3D, or the
+// frustum/backface-culled subset when EnableCulling is on. The length-only
+// cache key is only safe for the former: EnableCulling's subset can change
+// polygons frame-to-frame (the camera moved) while staying the same size,
+// so that path always rebuilds rather than trusting a coincidental length match.
+func (r *Renderer3D) ensureBSP(source []RenderItem) {
+	if !r.EnableCulling && r.bsp != nil && r.bspBuiltLen == len(source) {
+		return
+	}
+	polys := make([]RenderItem, 0, len(source))
+	for _, item := range source {
+		if len(item.Points) >= 3 {
+			polys = append(polys, item)
+		}
+	}
+	r.bsp = NewBSPTree(polys)
+	r.bspBuiltLen = len(source)
+}
+
+// rebuild recomposes Render3D from the static ground plus the current blocks.
+func (r *Renderer3D) rebuild() {
+	r.Render3D = append(append([]RenderItem{}, r.groundItems...), r.blockItems()...)
+}
+
+func (r *Renderer3D) blockItems() []RenderItem {
+	items := make([]RenderItem, 0, len(r.PlacedBlocks)*6)
+	for _, b := range r.PlacedBlocks {
+		items = append(items, r.NewCube([]color.Color{b.Color}, b.Pos, r3.Vec{}, r.GridSize, r.GridSize, r.GridSize, true)...)
+	}
+	return items
+}
+
+// SetGround stores the current Render3D contents as the static ground layer,
+// so later block placement/removal can rebuild without re-generating it.
+func (r *Renderer3D) SetGround(items []RenderItem) {
+	r.groundItems = items
+	r.rebuild()
+}
+
+// voxelKey converts a grid-aligned world position into its occupancy cell.
+func voxelKey(pos r3.Vec, gridSize float64) [3]int {
+	return [3]int{
+		int(math.Round(pos.X / gridSize)),
+		int(math.Round(pos.Y / gridSize)),
+		int(math.Round(pos.Z / gridSize)),
+	}
+}
+
+// PlaceBlock adds a block at pos if the cell is empty, rebuilding Render3D.
+func (r *Renderer3D) PlaceBlock(pos r3.Vec, col color.Color) bool {
+	if r.occupancy == nil {
+		r.occupancy = map[[3]int]int{}
+	}
+	key := voxelKey(pos, r.GridSize)
+	if _, ok := r.occupancy[key]; ok {
+		return false
+	}
+	r.occupancy[key] = len(r.PlacedBlocks)
+	r.PlacedBlocks = append(r.PlacedBlocks, PlacedBlock{Pos: pos, Color: col})
+	r.rebuild()
+	return true
+}
+
+// RemoveBlock deletes the block at pos, if any, rebuilding Render3D.
+func (r *Renderer3D) RemoveBlock(pos r3.Vec) bool {
+	key := voxelKey(pos, r.GridSize)
+	idx, ok := r.occupancy[key]
+	if !ok {
+		return false
+	}
+	last := len(r.PlacedBlocks) - 1
+	if idx != last {
+		r.PlacedBlocks[idx] = r.PlacedBlocks[last]
+		r.occupancy[voxelKey(r.PlacedBlocks[idx].Pos, r.GridSize)] = idx
+	}
+	r.PlacedBlocks = r.PlacedBlocks[:last]
+	delete(r.occupancy, key)
+	r.rebuild()
+	return true
+}
+
+// rebuildOccupancy recomputes the occupancy index from PlacedBlocks, e.g.
+// after LoadOBJ replaces the slice wholesale.
+func (r *Renderer3D) rebuildOccupancy() {
+	r.occupancy = make(map[[3]int]int, len(r.PlacedBlocks))
+	for i, b := range r.PlacedBlocks {
+		r.occupancy[voxelKey(b.Pos, r.GridSize)] = i
+	}
+}
+
+// VoxelHit is the result of an Amanatides-Woo grid DDA raycast: the voxel
+// cell touched and the face normal of the boundary the ray crossed to
+// reach it (or, for Ground, the top face of the y==0 baseplate).
+type VoxelHit struct {
+	Block  [3]int
+	Normal r3.Vec
+	Ground bool
+}
+
+// CastVoxelRay walks the voxel grid from origin along dir using the
+// Amanatides-Woo DDA, stopping at the first occupied PlacedBlocks cell or
+// the y==0 baseplate. The returned Normal is the face the ray entered
+// through, exact for every hit (no abs(localPos) heuristic).
+func (r *Renderer3D) CastVoxelRay(origin, dir r3.Vec) (VoxelHit, bool) {
+	length := math.Sqrt(dir.X*dir.X + dir.Y*dir.Y + dir.Z*dir.Z)
+	if length == 0 {
+		return VoxelHit{}, false
+	}
+	dir = r3.Scale(1/length, dir)
+	gs := r.GridSize
+
+	ix := int(math.Floor(origin.X / gs))
+	iy := int(math.Floor(origin.Y / gs))
+	iz := int(math.Floor(origin.Z / gs))
+
+	axisStep := func(d float64) int {
+		switch {
+		case d > 0:
+			return 1
+		case d < 0:
+			return -1
+		default:
+			return 0
+		}
+	}
+	stepX, stepY, stepZ := axisStep(dir.X), axisStep(dir.Y), axisStep(dir.Z)
+
+	tDelta := func(d float64) float64 {
+		if d == 0 {
+			return math.Inf(1)
+		}
+		return gs / math.Abs(d)
+	}
+	tDeltaX, tDeltaY, tDeltaZ := tDelta(dir.X), tDelta(dir.Y), tDelta(dir.Z)
+
+	tMaxFor := func(o, d float64, i, s int) float64 {
+		if d == 0 {
+			return math.Inf(1)
+		}
+		boundary := float64(i) * gs
+		if s > 0 {
+			boundary += gs
+		}
+		return (boundary - o) / d
+	}
+	tMaxX := tMaxFor(origin.X, dir.X, ix, stepX)
+	tMaxY := tMaxFor(origin.Y, dir.Y, iy, stepY)
+	tMaxZ := tMaxFor(origin.Z, dir.Z, iz, stepZ)
+
+	var normal r3.Vec
+	// crossedGround is true once the walk is below row 0 (iy < 0), i.e.
+	// the ray has passed down through the y==0 baseplate; only Y-axis
+	// steps change iy, so it is updated there.
+	crossedGround := iy < 0
+	maxSteps := int(r.renderDistance / gs)
+	for n := 0; n < maxSteps; n++ {
+		if _, occupied := r.occupancy[[3]int{ix, iy, iz}]; occupied {
+			return VoxelHit{Block: [3]int{ix, iy, iz}, Normal: normal}, true
+		}
+		if crossedGround {
+			// The baseplate is the row just below 0, so Block+Normal is the
+			// row-0 cell sitting on it.
+			return VoxelHit{Block: [3]int{ix, -1, iz}, Normal: r3.Vec{Y: 1}, Ground: true}, true
+		}
+
+		switch {
+		case tMaxX < tMaxY && tMaxX < tMaxZ:
+			ix += stepX
+			tMaxX += tDeltaX
+			normal = r3.Vec{X: -float64(stepX)}
+		case tMaxY < tMaxZ:
+			iy += stepY
+			tMaxY += tDeltaY
+			normal = r3.Vec{Y: -float64(stepY)}
+			crossedGround = iy < 0
+		default:
+			iz += stepZ
+			tMaxZ += tDeltaZ
+			normal = r3.Vec{Z: -float64(stepZ)}
+		}
+	}
+	return VoxelHit{}, false
 }
 
 func tick(lastTick *time.Time, targetFPS int) float64 {
@@ -73,14 +329,22 @@ func RotationMatrix(yawDeg, pitchDeg, rollDeg float64) *mat.Dense {
 }
 
 func (r *Renderer3D) convert3DTo2D(point r3.Vec) (pixel.Vec, bool) {
+	p, _, ok := r.projectCam(point)
+	return p, ok
+}
+
+// projectCam projects point into screen space like convert3DTo2D, also
+// returning the camera-space depth so callers can perspective-correct
+// interpolate attributes (e.g. texture UVs).
+func (r *Renderer3D) projectCam(point r3.Vec) (pixel.Vec, float64, bool) {
 	diff := r3.Vec{X: point.X - r.cPOS.X, Y: point.Y - r.cPOS.Y, Z: point.Z - r.cPOS.Z}
 	pointCam := multiplyMatVec(r.cachedRotationMatrix, diff)
 	if pointCam.Z <= 0 || math.Sqrt(pointCam.X*pointCam.X+pointCam.Y*pointCam.Y+pointCam.Z*pointCam.Z) > r.renderDistance {
-		return pixel.ZV, false
+		return pixel.ZV, 0, false
 	}
 	x2d := r.fov*(pointCam.X/pointCam.Z) + r.screenw/2
 	y2d := r.fov*(pointCam.Y/pointCam.Z) + r.screenh/2
-	return pixel.V(x2d, y2d), true
+	return pixel.V(x2d, y2d), pointCam.Z, true
 }
 
 func (r *Renderer3D) NewCube(colors []color.Color, pos, orientation r3.Vec, lx, ly, lz float64, fill bool) []RenderItem {
@@ -98,11 +362,15 @@ func (r *Renderer3D) NewCube(colors []color.Color, pos, orientation r3.Vec, lx,
 		verts[i] = r3.Vec{X: rv.X + pos.X, Y: rv.Y + pos.Y, Z: rv.Z + pos.Z}
 	}
 	if fill {
+		// Point order is chosen so faceNormal(face.Points) (cross of the
+		// first two edges) always points outward, matching the backface
+		// cull in Tick; three of these loops run opposite the other
+		// three to land on a consistently outward winding.
 		return []RenderItem{
-			{Points: []r3.Vec{verts[0], verts[1], verts[4], verts[2]}, Color: colors[0]},
+			{Points: []r3.Vec{verts[0], verts[2], verts[4], verts[1]}, Color: colors[0]},
 			{Points: []r3.Vec{verts[0], verts[1], verts[5], verts[3]}, Color: colors[1]},
-			{Points: []r3.Vec{verts[0], verts[2], verts[6], verts[3]}, Color: colors[2]},
-			{Points: []r3.Vec{verts[4], verts[7], verts[6], verts[2]}, Color: colors[3]},
+			{Points: []r3.Vec{verts[0], verts[3], verts[6], verts[2]}, Color: colors[2]},
+			{Points: []r3.Vec{verts[4], verts[2], verts[6], verts[7]}, Color: colors[3]},
 			{Points: []r3.Vec{verts[5], verts[7], verts[6], verts[3]}, Color: colors[4]},
 			{Points: []r3.Vec{verts[4], verts[7], verts[5], verts[1]}, Color: colors[5]},
 		}
@@ -116,34 +384,876 @@ func (r *Renderer3D) NewCube(colors []color.Color, pos, orientation r3.Vec, lx,
 	return edges
 }
 
+// cubeFaceUVs are the per-vertex texture coordinates for a single cube
+// face, matching the winding order NewCube emits for each face.
+var cubeFaceUVs = []pixel.Vec{pixel.V(0, 0), pixel.V(1, 0), pixel.V(1, 1), pixel.V(0, 1)}
+
+// NewTexturedCube builds a cube like NewCube(fill=true) but assigns tex and
+// per-vertex UVs to each face so Tick rasterizes it instead of flat-filling.
+func (r *Renderer3D) NewTexturedCube(tex image.Image, pos, orientation r3.Vec, lx, ly, lz float64) []RenderItem {
+	faces := r.NewCube([]color.Color{colornames.White}, pos, orientation, lx, ly, lz, true)
+	for i := range faces {
+		faces[i].Texture = tex
+		faces[i].UVs = cubeFaceUVs
+	}
+	return faces
+}
+
+// NewCheckerboard renders a size x size image.Image alternating between a
+// and b in squares squares-per-side, for use as a quick ground/block
+// texture asset without needing an external file.
+func NewCheckerboard(size, squares int, a, b color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	cell := size / squares
+	if cell == 0 {
+		cell = 1
+	}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			c := a
+			if (x/cell+y/cell)%2 == 1 {
+				c = b
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// SaveOBJ writes the current PlacedBlocks to a Wavefront OBJ file at path,
+// one group per block, with colors split into a companion .mtl referenced
+// by usemtl/mtllib so the scene can be opened in Blender/MeshLab.
+func (r *Renderer3D) SaveOBJ(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	mtlPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".mtl"
+	mf, err := os.Create(mtlPath)
+	if err != nil {
+		return err
+	}
+	defer mf.Close()
+
+	w := bufio.NewWriter(f)
+	defer w.Flush()
+	mw := bufio.NewWriter(mf)
+	defer mw.Flush()
+
+	fmt.Fprintf(w, "mtllib %s\n", filepath.Base(mtlPath))
+
+	seenMat := map[string]bool{}
+	vCount, vnCount := 0, 0
+	for i, b := range r.PlacedBlocks {
+		cube := r.NewCube([]color.Color{b.Color}, b.Pos, r3.Vec{}, r.GridSize, r.GridSize, r.GridSize, true)
+		matName := fmt.Sprintf("mat%06x", colorToRGB(b.Color))
+		if !seenMat[matName] {
+			seenMat[matName] = true
+			cr, cg, cb, _ := b.Color.RGBA()
+			fmt.Fprintf(mw, "newmtl %s\nKd %f %f %f\n", matName, float64(cr)/65535, float64(cg)/65535, float64(cb)/65535)
+		}
+
+		fmt.Fprintf(w, "g block%d\n", i)
+		fmt.Fprintf(w, "usemtl %s\n", matName)
+
+		verts := map[r3.Vec]int{}
+		for _, face := range cube {
+			for _, p := range face.Points {
+				if _, ok := verts[p]; !ok {
+					vCount++
+					verts[p] = vCount
+					fmt.Fprintf(w, "v %f %f %f\n", p.X, p.Y, p.Z)
+				}
+			}
+		}
+		for _, face := range cube {
+			n := faceNormal(face.Points)
+			if n == (r3.Vec{}) {
+				fmt.Println("SaveOBJ: skipping degenerate face")
+				continue
+			}
+			vnCount++
+			fmt.Fprintf(w, "vn %f %f %f\n", n.X, n.Y, n.Z)
+			fmt.Fprint(w, "f")
+			for _, p := range face.Points {
+				fmt.Fprintf(w, " %d//%d", verts[p], vnCount)
+			}
+			fmt.Fprint(w, "\n")
+		}
+	}
+	return nil
+}
+
+// LoadOBJ parses an OBJ previously written by SaveOBJ, rebuilding
+// PlacedBlocks and Render3D by calling NewCube for each group. Colors are
+// resolved via the mtllib/usemtl pair against the companion .mtl file
+// SaveOBJ wrote, falling back to gray for a group with no resolvable
+// material. A group's position comes from its vertex bounding box; a group
+// that has faces is only reconstructed if at least one of them has valid
+// vertex references, while a group with vertices and no faces at all is
+// still reconstructed. Degenerate groups and groups with nothing but
+// malformed faces are skipped with a warning rather than aborting.
+func (r *Renderer3D) LoadOBJ(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	mtlColors := map[string]color.Color{}
+	var blocks []PlacedBlock
+	var groupVerts []r3.Vec
+	var groupMat string
+	vCount := 0
+	groupFaces, groupValidFaces := 0, 0
+
+	flush := func() {
+		defer func() {
+			groupVerts, groupMat = nil, ""
+			groupFaces, groupValidFaces = 0, 0
+		}()
+		if len(groupVerts) == 0 {
+			return
+		}
+		if groupFaces > 0 && groupValidFaces == 0 {
+			fmt.Println("LoadOBJ: skipping group with no valid faces")
+			return
+		}
+		min, max := groupVerts[0], groupVerts[0]
+		for _, v := range groupVerts {
+			min = r3.Vec{X: math.Min(min.X, v.X), Y: math.Min(min.Y, v.Y), Z: math.Min(min.Z, v.Z)}
+			max = r3.Vec{X: math.Max(max.X, v.X), Y: math.Max(max.Y, v.Y), Z: math.Max(max.Z, v.Z)}
+		}
+		size := r3.Sub(max, min)
+		if size.X <= 0 || size.Y <= 0 || size.Z <= 0 {
+			fmt.Println("LoadOBJ: skipping degenerate group")
+			return
+		}
+		col := color.Color(colornames.Gray)
+		if c, ok := mtlColors[groupMat]; ok {
+			col = c
+		}
+		blocks = append(blocks, PlacedBlock{Pos: min, Color: col})
+	}
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "mtllib":
+			if len(fields) < 2 {
+				continue
+			}
+			colors, err := loadMTLColors(filepath.Join(filepath.Dir(path), fields[1]))
+			if err != nil {
+				fmt.Printf("LoadOBJ: skipping mtllib %s: %v\n", fields[1], err)
+				continue
+			}
+			mtlColors = colors
+		case "g":
+			flush()
+		case "usemtl":
+			if len(fields) >= 2 {
+				groupMat = fields[1]
+			}
+		case "v":
+			if len(fields) < 4 {
+				fmt.Println("LoadOBJ: skipping malformed vertex")
+				continue
+			}
+			x, _ := strconv.ParseFloat(fields[1], 64)
+			y, _ := strconv.ParseFloat(fields[2], 64)
+			z, _ := strconv.ParseFloat(fields[3], 64)
+			v := r3.Vec{X: x, Y: y, Z: z}
+			groupVerts = append(groupVerts, v)
+			vCount++
+		case "f":
+			groupFaces++
+			valid := len(fields) >= 4
+			for _, tok := range fields[1:] {
+				idx, err := strconv.Atoi(strings.SplitN(tok, "/", 2)[0])
+				if err != nil || idx < 1 || idx > vCount {
+					valid = false
+					break
+				}
+			}
+			if valid {
+				groupValidFaces++
+			} else {
+				fmt.Println("LoadOBJ: skipping degenerate or malformed face")
+			}
+		}
+	}
+	flush()
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	r.PlacedBlocks = blocks
+	r.rebuildOccupancy()
+	r.rebuild()
+	return nil
+}
+
+// loadMTLColors parses newmtl/Kd pairs from an OBJ companion material
+// file into the inverse of the conversion SaveOBJ performs when writing Kd.
+func loadMTLColors(path string) (map[string]color.Color, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	colors := map[string]color.Color{}
+	var current string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "newmtl":
+			if len(fields) >= 2 {
+				current = fields[1]
+			}
+		case "Kd":
+			if current == "" || len(fields) < 4 {
+				continue
+			}
+			kr, _ := strconv.ParseFloat(fields[1], 64)
+			kg, _ := strconv.ParseFloat(fields[2], 64)
+			kb, _ := strconv.ParseFloat(fields[3], 64)
+			colors[current] = color.RGBA{
+				R: uint8(math.Round(clampF(kr, 0, 1) * 255)),
+				G: uint8(math.Round(clampF(kg, 0, 1) * 255)),
+				B: uint8(math.Round(clampF(kb, 0, 1) * 255)),
+				A: 255,
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return colors, nil
+}
+
+func faceNormal(pts []r3.Vec) r3.Vec {
+	if len(pts) < 3 {
+		return r3.Vec{}
+	}
+	e1 := r3.Sub(pts[1], pts[0])
+	e2 := r3.Sub(pts[2], pts[0])
+	n := r3.Cross(e1, e2)
+	length := math.Sqrt(n.X*n.X + n.Y*n.Y + n.Z*n.Z)
+	if length == 0 {
+		return n
+	}
+	return r3.Scale(1/length, n)
+}
+
+func colorToRGB(c color.Color) uint32 {
+	r, g, b, _ := c.RGBA()
+	return (r>>8)<<16 | (g>>8)<<8 | (b >> 8)
+}
+
+// bspPlane is a splitting plane in n·p + d = 0 form.
+type bspPlane struct {
+	n r3.Vec
+	d float64
+}
+
+func (p bspPlane) dist(v r3.Vec) float64 {
+	return p.n.X*v.X + p.n.Y*v.Y + p.n.Z*v.Z + p.d
+}
+
+type bspNode struct {
+	plane    bspPlane
+	coplanar []RenderItem
+	front    *bspNode
+	back     *bspNode
+}
+
+const bspEpsilon = 1e-6
+
+// BSPTree orders filled polygons back-to-front relative to a viewpoint,
+// splitting any polygon that straddles a node's plane so intersecting or
+// camera-straddling faces still draw in the correct order.
+type BSPTree struct {
+	root *bspNode
+}
+
+// NewBSPTree builds a tree over polys (each with >= 3 points), picking each
+// node's splitting plane from the first remaining polygon.
+func NewBSPTree(polys []RenderItem) *BSPTree {
+	return &BSPTree{root: buildBSPNode(polys)}
+}
+
+func buildBSPNode(polys []RenderItem) *bspNode {
+	if len(polys) == 0 {
+		return nil
+	}
+	plane := planeFromPolygon(polys[0].Points)
+	node := &bspNode{plane: plane, coplanar: []RenderItem{polys[0]}}
+
+	var front, back []RenderItem
+	for _, poly := range polys[1:] {
+		classifyPolygon(plane, poly, node, &front, &back)
+	}
+	node.front = buildBSPNode(front)
+	node.back = buildBSPNode(back)
+	return node
+}
+
+func planeFromPolygon(pts []r3.Vec) bspPlane {
+	n := faceNormal(pts)
+	return bspPlane{n: n, d: -(n.X*pts[0].X + n.Y*pts[0].Y + n.Z*pts[0].Z)}
+}
+
+func classifyPolygon(plane bspPlane, poly RenderItem, node *bspNode, front, back *[]RenderItem) {
+	numFront, numBack := 0, 0
+	dists := make([]float64, len(poly.Points))
+	for i, p := range poly.Points {
+		dists[i] = plane.dist(p)
+		switch {
+		case dists[i] > bspEpsilon:
+			numFront++
+		case dists[i] < -bspEpsilon:
+			numBack++
+		}
+	}
+	switch {
+	case numFront == 0 && numBack == 0:
+		node.coplanar = append(node.coplanar, poly)
+	case numBack == 0:
+		*front = append(*front, poly)
+	case numFront == 0:
+		*back = append(*back, poly)
+	default:
+		frontPts, backPts := splitPolygon(plane, poly.Points, dists)
+		if len(frontPts) >= 3 {
+			*front = append(*front, RenderItem{Points: frontPts, Color: poly.Color})
+		}
+		if len(backPts) >= 3 {
+			*back = append(*back, RenderItem{Points: backPts, Color: poly.Color})
+		}
+	}
+}
+
+// splitPolygon clips a convex polygon against plane, returning the portion
+// on each side; edges that cross the plane are cut at the intersection t.
+func splitPolygon(plane bspPlane, pts []r3.Vec, dists []float64) (front, back []r3.Vec) {
+	n := len(pts)
+	for i := 0; i < n; i++ {
+		curr, next := pts[i], pts[(i+1)%n]
+		dCurr, dNext := dists[i], dists[(i+1)%n]
+
+		if dCurr >= -bspEpsilon {
+			front = append(front, curr)
+		}
+		if dCurr <= bspEpsilon {
+			back = append(back, curr)
+		}
+		if (dCurr > bspEpsilon && dNext < -bspEpsilon) || (dCurr < -bspEpsilon && dNext > bspEpsilon) {
+			denom := plane.n.X*(next.X-curr.X) + plane.n.Y*(next.Y-curr.Y) + plane.n.Z*(next.Z-curr.Z)
+			t := -dCurr / denom
+			mid := r3.Add(curr, r3.Scale(t, r3.Sub(next, curr)))
+			front = append(front, mid)
+			back = append(back, mid)
+		}
+	}
+	return
+}
+
+// Traverse returns polys back-to-front relative to viewpoint: far subtree,
+// this node's coplanar polygons, then near subtree.
+func (t *BSPTree) Traverse(viewpoint r3.Vec) []RenderItem {
+	var out []RenderItem
+	traverseBSP(t.root, viewpoint, &out)
+	return out
+}
+
+func traverseBSP(node *bspNode, viewpoint r3.Vec, out *[]RenderItem) {
+	if node == nil {
+		return
+	}
+	near, far := node.front, node.back
+	if node.plane.dist(viewpoint) < 0 {
+		near, far = node.back, node.front
+	}
+	traverseBSP(far, viewpoint, out)
+	*out = append(*out, node.coplanar...)
+	traverseBSP(near, viewpoint, out)
+}
+
+// AABB is an axis-aligned bounding box.
+type AABB struct {
+	Min, Max r3.Vec
+}
+
+func itemAABB(item RenderItem) AABB {
+	min, max := item.Points[0], item.Points[0]
+	for _, p := range item.Points[1:] {
+		min = r3.Vec{X: math.Min(min.X, p.X), Y: math.Min(min.Y, p.Y), Z: math.Min(min.Z, p.Z)}
+		max = r3.Vec{X: math.Max(max.X, p.X), Y: math.Max(max.Y, p.Y), Z: math.Max(max.Z, p.Z)}
+	}
+	return AABB{Min: min, Max: max}
+}
+
+func unionAABB(a, b AABB) AABB {
+	return AABB{
+		Min: r3.Vec{X: math.Min(a.Min.X, b.Min.X), Y: math.Min(a.Min.Y, b.Min.Y), Z: math.Min(a.Min.Z, b.Min.Z)},
+		Max: r3.Vec{X: math.Max(a.Max.X, b.Max.X), Y: math.Max(a.Max.Y, b.Max.Y), Z: math.Max(a.Max.Z, b.Max.Z)},
+	}
+}
+
+type octreeEntry struct {
+	bounds AABB
+	index  int
+}
+
+const (
+	octreeMaxEntries = 8
+	octreeMaxDepth   = 6
+)
+
+// Octree partitions Render3D's polygon AABBs for fast frustum queries,
+// rebuilt lazily (see Renderer3D.ensureOctree) as blocks are placed.
+type Octree struct {
+	bounds   AABB
+	entries  []octreeEntry
+	children [8]*Octree
+}
+
+// NewOctree builds a tree over items' AABBs (indices refer back into items).
+func NewOctree(items []RenderItem) *Octree {
+	if len(items) == 0 {
+		return &Octree{}
+	}
+	entries := make([]octreeEntry, len(items))
+	bounds := itemAABB(items[0])
+	for i, it := range items {
+		b := itemAABB(it)
+		entries[i] = octreeEntry{bounds: b, index: i}
+		bounds = unionAABB(bounds, b)
+	}
+	root := &Octree{bounds: bounds}
+	root.insert(entries, 0)
+	return root
+}
+
+func (o *Octree) insert(entries []octreeEntry, depth int) {
+	if len(entries) <= octreeMaxEntries || depth >= octreeMaxDepth {
+		o.entries = entries
+		return
+	}
+	center := r3.Scale(0.5, r3.Add(o.bounds.Min, o.bounds.Max))
+	var buckets [8][]octreeEntry
+	for _, e := range entries {
+		oct := octantIndex(e.bounds, center)
+		buckets[oct] = append(buckets[oct], e)
+	}
+	for i, b := range buckets {
+		if len(b) == 0 {
+			continue
+		}
+		child := &Octree{bounds: octantBounds(o.bounds, center, i)}
+		child.insert(b, depth+1)
+		o.children[i] = child
+	}
+}
+
+func octantIndex(b AABB, center r3.Vec) int {
+	mid := r3.Scale(0.5, r3.Add(b.Min, b.Max))
+	idx := 0
+	if mid.X >= center.X {
+		idx |= 1
+	}
+	if mid.Y >= center.Y {
+		idx |= 2
+	}
+	if mid.Z >= center.Z {
+		idx |= 4
+	}
+	return idx
+}
+
+func octantBounds(parent AABB, center r3.Vec, idx int) AABB {
+	min, max := parent.Min, parent.Max
+	if idx&1 != 0 {
+		min.X = center.X
+	} else {
+		max.X = center.X
+	}
+	if idx&2 != 0 {
+		min.Y = center.Y
+	} else {
+		max.Y = center.Y
+	}
+	if idx&4 != 0 {
+		min.Z = center.Z
+	} else {
+		max.Z = center.Z
+	}
+	return AABB{Min: min, Max: max}
+}
+
+// Query appends the index of every entry whose AABB intersects f into out,
+// skipping any subtree whose bounds lie entirely outside a frustum plane.
+func (o *Octree) Query(f *Frustum, out *[]int) {
+	if o == nil || !f.IntersectsAABB(o.bounds) {
+		return
+	}
+	for _, e := range o.entries {
+		if f.IntersectsAABB(e.bounds) {
+			*out = append(*out, e.index)
+		}
+	}
+	for _, c := range o.children {
+		c.Query(f, out)
+	}
+}
+
+// FrustumPlane is a plane in n·p + d = 0 form, normal pointing inward.
+type FrustumPlane struct {
+	N r3.Vec
+	D float64
+}
+
+func (p FrustumPlane) dist(v r3.Vec) float64 {
+	return p.N.X*v.X + p.N.Y*v.Y + p.N.Z*v.Z + p.D
+}
+
+// Frustum is six inward-facing planes: near, far, left, right, top, bottom.
+type Frustum struct {
+	Planes [6]FrustumPlane
+}
+
+// IntersectsAABB uses the p-vertex trick: a box is entirely outside a plane
+// only if its most-positive corner (relative to the plane's normal) is
+// still behind it.
+func (f *Frustum) IntersectsAABB(b AABB) bool {
+	for _, p := range f.Planes {
+		pv := r3.Vec{}
+		if p.N.X >= 0 {
+			pv.X = b.Max.X
+		} else {
+			pv.X = b.Min.X
+		}
+		if p.N.Y >= 0 {
+			pv.Y = b.Max.Y
+		} else {
+			pv.Y = b.Min.Y
+		}
+		if p.N.Z >= 0 {
+			pv.Z = b.Max.Z
+		} else {
+			pv.Z = b.Min.Z
+		}
+		if p.dist(pv) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func dot(a, b r3.Vec) float64 { return a.X*b.X + a.Y*b.Y + a.Z*b.Z }
+
+func normalize(v r3.Vec) r3.Vec {
+	l := math.Sqrt(dot(v, v))
+	if l == 0 {
+		return v
+	}
+	return r3.Scale(1/l, v)
+}
+
+// rotateAroundAxis rotates v by angle radians around axis (Rodrigues'
+// rotation formula); axis must be a unit vector.
+func rotateAroundAxis(v, axis r3.Vec, angle float64) r3.Vec {
+	cosA, sinA := math.Cos(angle), math.Sin(angle)
+	term1 := r3.Scale(cosA, v)
+	term2 := r3.Scale(sinA, r3.Cross(axis, v))
+	term3 := r3.Scale(dot(axis, v)*(1-cosA), axis)
+	return r3.Add(r3.Add(term1, term2), term3)
+}
+
+// NewFrustum derives the six view planes from the camera basis (forward,
+// right, up), fov, screen size, and near/far distances. fov is the same
+// pixel-scale constant used by convert3DTo2D, so the horizontal/vertical
+// half-angles are recovered as atan((screen/2)/fov).
+func NewFrustum(cPos, forward, right, up r3.Vec, fov, screenw, screenh, near, far float64) *Frustum {
+	halfX := math.Atan((screenw / 2) / fov)
+	halfY := math.Atan((screenh / 2) / fov)
+
+	mkSidePlane := func(edge, axis r3.Vec) FrustumPlane {
+		n := normalize(r3.Cross(axis, edge))
+		if dot(n, forward) < 0 {
+			n = r3.Scale(-1, n)
+		}
+		return FrustumPlane{N: n, D: -dot(n, cPos)}
+	}
+
+	left := mkSidePlane(rotateAroundAxis(forward, up, halfX), up)
+	right2 := mkSidePlane(rotateAroundAxis(forward, up, -halfX), up)
+	top := mkSidePlane(rotateAroundAxis(forward, right, halfY), right)
+	bottom := mkSidePlane(rotateAroundAxis(forward, right, -halfY), right)
+
+	near2 := FrustumPlane{N: forward, D: -dot(forward, cPos) - near}
+	far2 := FrustumPlane{N: r3.Scale(-1, forward), D: dot(forward, cPos) + far}
+
+	return &Frustum{Planes: [6]FrustumPlane{near2, far2, left, right2, top, bottom}}
+}
+
+// texVertex is a screen-space vertex carrying perspective-correct
+// interpolants: camera-space 1/z and UV/z, so per-pixel division recovers
+// the correct (u,v) under perspective.
+type texVertex struct {
+	pos            pixel.Vec
+	invZ           float64
+	uOverZ, vOverZ float64
+}
+
+func lerp(a, b, t float64) float64 { return a + (b-a)*t }
+
+func edgeInterp(a, b texVertex, y float64) texVertex {
+	t := 0.0
+	if b.pos.Y != a.pos.Y {
+		t = (y - a.pos.Y) / (b.pos.Y - a.pos.Y)
+	}
+	return texVertex{
+		pos:    pixel.V(lerp(a.pos.X, b.pos.X, t), y),
+		invZ:   lerp(a.invZ, b.invZ, t),
+		uOverZ: lerp(a.uOverZ, b.uOverZ, t),
+		vOverZ: lerp(a.vOverZ, b.vOverZ, t),
+	}
+}
+
+// rasterizeTriangle scanline-fills a, b, c into frame, sampling tex with
+// perspective-correct UVs recovered by dividing the interpolated u/z, v/z
+// by the interpolated 1/z at each pixel.
+func rasterizeTriangle(frame *pixel.PictureData, a, b, c texVertex, tex image.Image) {
+	verts := []texVertex{a, b, c}
+	sort.Slice(verts, func(i, j int) bool { return verts[i].pos.Y < verts[j].pos.Y })
+	top, mid, bot := verts[0], verts[1], verts[2]
+
+	y0, y1 := int(math.Ceil(top.pos.Y)), int(math.Floor(bot.pos.Y))
+	for y := y0; y <= y1; y++ {
+		fy := float64(y)
+		vLong := edgeInterp(top, bot, fy)
+		var vShort texVertex
+		if fy < mid.pos.Y {
+			vShort = edgeInterp(top, mid, fy)
+		} else {
+			vShort = edgeInterp(mid, bot, fy)
+		}
+		left, right := vLong, vShort
+		if left.pos.X > right.pos.X {
+			left, right = right, left
+		}
+		x0, x1 := int(math.Ceil(left.pos.X)), int(math.Floor(right.pos.X))
+		for x := x0; x <= x1; x++ {
+			t := 0.0
+			if right.pos.X != left.pos.X {
+				t = (float64(x) - left.pos.X) / (right.pos.X - left.pos.X)
+			}
+			invZ := lerp(left.invZ, right.invZ, t)
+			if invZ <= 0 {
+				continue
+			}
+			u := lerp(left.uOverZ, right.uOverZ, t) / invZ
+			v := lerp(left.vOverZ, right.vOverZ, t) / invZ
+			setFramePixel(frame, x, y, sampleTexture(tex, u, v))
+		}
+	}
+}
+
+func sampleTexture(tex image.Image, u, v float64) color.Color {
+	b := tex.Bounds()
+	u -= math.Floor(u)
+	v -= math.Floor(v)
+	x := b.Min.X + int(u*float64(b.Dx()))
+	y := b.Min.Y + int((1-v)*float64(b.Dy()))
+	if x >= b.Max.X {
+		x = b.Max.X - 1
+	}
+	if y >= b.Max.Y {
+		y = b.Max.Y - 1
+	}
+	return tex.At(x, y)
+}
+
+func setFramePixel(frame *pixel.PictureData, x, y int, col color.Color) {
+	if x < int(frame.Rect.Min.X) || x >= int(frame.Rect.Max.X) || y < int(frame.Rect.Min.Y) || y >= int(frame.Rect.Max.Y) {
+		return
+	}
+	idx := frame.Index(pixel.V(float64(x), float64(y)))
+	if idx < 0 || idx >= len(frame.Pix) {
+		return
+	}
+	cr, cg, cb, ca := col.RGBA()
+	frame.Pix[idx] = color.RGBA{uint8(cr >> 8), uint8(cg >> 8), uint8(cb >> 8), uint8(ca >> 8)}
+}
+
+// rasterizeTextured projects item's vertices and fan-triangulates it into
+// frame, first clearing only item's own screen-space bounding box rather
+// than the whole buffer, so a Tick with many textured items (e.g. the
+// full ground grid) doesn't pay for a full-frame clear per item. Returns
+// false (skipping the polygon) if any vertex fails to project, matching
+// how non-textured polygons are dropped.
+func (r *Renderer3D) rasterizeTextured(frame *pixel.PictureData, item RenderItem) (pixel.Rect, bool) {
+	verts := make([]texVertex, len(item.Points))
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for i, pt := range item.Points {
+		proj, z, ok := r.projectCam(pt)
+		if !ok {
+			return pixel.Rect{}, false
+		}
+		invZ := 1 / z
+		verts[i] = texVertex{pos: proj, invZ: invZ, uOverZ: item.UVs[i].X * invZ, vOverZ: item.UVs[i].Y * invZ}
+		minX, maxX = math.Min(minX, proj.X), math.Max(maxX, proj.X)
+		minY, maxY = math.Min(minY, proj.Y), math.Max(maxY, proj.Y)
+	}
+	bounds := clampRectToFrame(pixel.R(minX-1, minY-1, maxX+1, maxY+1), frame)
+	clearFrameRect(frame, bounds)
+	for i := 1; i+1 < len(verts); i++ {
+		rasterizeTriangle(frame, verts[0], verts[i], verts[i+1], item.Texture)
+	}
+	return bounds, true
+}
+
+// clampRectToFrame clips rect to frame's bounds.
+func clampRectToFrame(rect pixel.Rect, frame *pixel.PictureData) pixel.Rect {
+	return pixel.R(
+		math.Max(rect.Min.X, frame.Rect.Min.X),
+		math.Max(rect.Min.Y, frame.Rect.Min.Y),
+		math.Min(rect.Max.X, frame.Rect.Max.X),
+		math.Min(rect.Max.Y, frame.Rect.Max.Y),
+	)
+}
+
+// clearFrameRect zeroes the pixels of frame within rect, leaving the rest
+// of the shared buffer untouched.
+func clearFrameRect(frame *pixel.PictureData, rect pixel.Rect) {
+	x0, x1 := int(math.Floor(rect.Min.X)), int(math.Ceil(rect.Max.X))
+	y0, y1 := int(math.Floor(rect.Min.Y)), int(math.Ceil(rect.Max.Y))
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			setFramePixel(frame, x, y, color.RGBA{})
+		}
+	}
+}
+
 func (r *Renderer3D) Tick(win pixel.Target) {
 	imd := imdraw.New(nil)
 	type obj struct {
-		depth  float64
 		points []pixel.Vec
 		col    color.Color
 	}
-	objs := []obj{}
-	for _, item := range r.Render3D {
-		points2d := []pixel.Vec{}
-		valid := true
-		sumDepth := 0.0
+
+	project := func(item RenderItem) (obj, bool) {
+		points2d := make([]pixel.Vec, 0, len(item.Points))
 		for _, pt := range item.Points {
 			proj, ok := r.convert3DTo2D(pt)
 			if !ok {
-				valid = false
-				break
+				return obj{}, false
 			}
 			points2d = append(points2d, proj)
-			dx, dy, dz := pt.X-r.cPOS.X, pt.Y-r.cPOS.Y, pt.Z-r.cPOS.Z
-			sumDepth += math.Sqrt(dx*dx + dy*dy + dz*dz)
 		}
-		if valid {
-			objs = append(objs, obj{depth: -sumDepth / float64(len(item.Points)), points: points2d, col: item.Color})
+		return obj{points: points2d, col: item.Color}, true
+	}
+
+	r.TotalCount = len(r.Render3D)
+	source := r.Render3D
+	if r.EnableCulling {
+		r.ensureOctree()
+		// The view direction is row 2: convert3DTo2D keeps only points with
+		// positive camera-space Z.
+		forward := r3.Vec{X: r.cachedRotationMatrix.At(2, 0), Y: r.cachedRotationMatrix.At(2, 1), Z: r.cachedRotationMatrix.At(2, 2)}
+		right := r3.Vec{X: r.cachedRotationMatrix.At(0, 0), Y: r.cachedRotationMatrix.At(0, 1), Z: r.cachedRotationMatrix.At(0, 2)}
+		up := r3.Vec{X: r.cachedRotationMatrix.At(1, 0), Y: r.cachedRotationMatrix.At(1, 1), Z: r.cachedRotationMatrix.At(1, 2)}
+		frustum := NewFrustum(r.cPOS, forward, right, up, r.fov, r.screenw, r.screenh, 0.1, r.renderDistance)
+
+		var visible []int
+		r.octree.Query(frustum, &visible)
+		culled := make([]RenderItem, 0, len(visible))
+		for _, idx := range visible {
+			item := r.Render3D[idx]
+			if len(item.Points) >= 3 {
+				n := faceNormal(item.Points)
+				if dot(r3.Sub(item.Points[0], r.cPOS), n) >= 0 {
+					continue // backface
+				}
+			}
+			culled = append(culled, item)
+		}
+		source = culled
+	}
+	r.VisibleCount = len(source)
+
+	var ordered []RenderItem
+	if r.UseBSP {
+		r.ensureBSP(source)
+		ordered = r.bsp.Traverse(r.cPOS)
+		for _, item := range source {
+			if len(item.Points) < 3 {
+				ordered = append(ordered, item)
+			}
+		}
+	} else {
+		type depthItem struct {
+			depth float64
+			item  RenderItem
+		}
+		depthItems := make([]depthItem, 0, len(source))
+		for _, item := range source {
+			sumDepth := 0.0
+			for _, pt := range item.Points {
+				dx, dy, dz := pt.X-r.cPOS.X, pt.Y-r.cPOS.Y, pt.Z-r.cPOS.Z
+				sumDepth += math.Sqrt(dx*dx + dy*dy + dz*dz)
+			}
+			depthItems = append(depthItems, depthItem{depth: -sumDepth / float64(len(item.Points)), item: item})
+		}
+		sort.Slice(depthItems, func(i, j int) bool { return depthItems[i].depth < depthItems[j].depth })
+		ordered = make([]RenderItem, len(depthItems))
+		for i, di := range depthItems {
+			ordered[i] = di.item
+		}
+	}
+
+	// Flat items accumulate into imd and textured items rasterize into
+	// frame, but both must hit win in ordered's back-to-front sequence or
+	// whichever batch draws second always wins the depth argument. So flush
+	// whichever batch is pending before switching kinds, rather than
+	// drawing all of one kind then all of the other.
+	frame := r.ensureFrame()
+	pending := false
+	flushFlat := func() {
+		if pending {
+			imd.Draw(win)
+			imd.Clear()
+			pending = false
 		}
 	}
-	sort.Slice(objs, func(i, j int) bool { return objs[i].depth < objs[j].depth })
-	for _, o := range objs {
+	for _, item := range ordered {
+		if item.Texture != nil && len(item.UVs) == len(item.Points) {
+			flushFlat()
+			if bounds, ok := r.rasterizeTextured(frame, item); ok {
+				sprite := pixel.NewSprite(frame, bounds)
+				sprite.Draw(win, pixel.IM.Moved(bounds.Center()))
+			}
+			continue
+		}
+		o, ok := project(item)
+		if !ok {
+			continue
+		}
 		imd.Color = o.col
 		switch len(o.points) {
 		case 1:
@@ -156,8 +1266,9 @@ func (r *Renderer3D) Tick(win pixel.Target) {
 			imd.Push(o.points...)
 			imd.Polygon(0)
 		}
+		pending = true
 	}
-	imd.Draw(win)
+	flushFlat()
 }
 
 func run() {
@@ -179,12 +1290,15 @@ func run() {
 	}
 
 	gridSize := 5
+	renderer.GridSize = float64(gridSize)
+	groundTex := NewCheckerboard(64, 4, colornames.Gray, colornames.Darkgray)
 	for x := -50; x <= 50; x += gridSize {
 		for z := -50; z <= 50; z += gridSize {
-			cube := renderer.NewCube([]color.Color{colornames.Gray}, r3.Vec{X: float64(x), Y: 0, Z: float64(z)}, r3.Vec{}, float64(gridSize), 1, float64(gridSize), true)
+			cube := renderer.NewTexturedCube(groundTex, r3.Vec{X: float64(x), Y: 0, Z: float64(z)}, r3.Vec{}, float64(gridSize), 1, float64(gridSize))
 			renderer.Render3D = append(renderer.Render3D, cube...)
 		}
 	}
+	renderer.SetGround(renderer.Render3D)
 
 	blockColors := []color.Color{
 		colornames.Red, colornames.Green, colornames.Blue, colornames.Yellow,
@@ -192,15 +1306,12 @@ func run() {
 		colornames.Brown, colornames.White,
 	}
 	selected := 0
-	placedBlocks := []r3.Vec{}
 
 	atlas := text.NewAtlas(basicfont.Face7x13, text.ASCII)
 	txt := text.New(pixel.V(10, 580), atlas)
 
 	lastTick := time.Now()
-	yaw, pitch, roll := 0.0, 0.0, 0.0
-	var lastPos r3.Vec
-	var lastYaw, lastPitch, lastRoll float64
+	cam := NewCamera()
 	mouseGrabbed := true
 	firstClickAfterFocus := false
 	center := win.Bounds().Center()
@@ -229,25 +1340,20 @@ func run() {
 		}
 
 		if mouseGrabbed {
-			yaw += mouseDelta.X * 0.2
-			pitch -= mouseDelta.Y * 0.2
-			if pitch > 85 {
-				pitch = 85
-			} else if pitch < -85 {
-				pitch = -85
-			}
+			cam.Rotate(-mouseDelta.Y*0.2, mouseDelta.X*0.2, 0)
 		}
-
-		if renderer.cPOS != lastPos || yaw != lastYaw || pitch != lastPitch || roll != lastRoll {
-			renderer.cachedRotationMatrix = RotationMatrix(yaw, pitch, roll)
-			lastPos = renderer.cPOS
-			lastYaw, lastPitch, lastRoll = yaw, pitch, roll
+		rollSpeed := 60.0 * dt
+		if win.Pressed(pixelgl.KeyQ) {
+			cam.Rotate(0, 0, -rollSpeed)
+		}
+		if win.Pressed(pixelgl.KeyE) {
+			cam.Rotate(0, 0, rollSpeed)
 		}
 
-		rot := renderer.cachedRotationMatrix
-		forward := r3.Vec{X: -rot.At(2, 0), Y: -rot.At(2, 1), Z: -rot.At(2, 2)}
-		right := r3.Vec{X: rot.At(0, 0), Y: rot.At(0, 1), Z: rot.At(0, 2)}
-		up := r3.Vec{X: rot.At(1, 0), Y: rot.At(1, 1), Z: rot.At(1, 2)}
+		cam.Pos = renderer.cPOS
+		renderer.cachedRotationMatrix = cam.Matrix()
+
+		forward, right, up := cam.Forward(), cam.Right(), cam.Up()
 
 		speed := 20.0 * dt
 		if win.Pressed(pixelgl.KeyW) {
@@ -270,80 +1376,27 @@ func run() {
 		}
 
 		if win.JustPressed(pixelgl.MouseButtonLeft) && mouseGrabbed && !firstClickAfterFocus {
-			forwardNorm := r3.Scale(1/math.Sqrt(forward.X*forward.X+forward.Y*forward.Y+forward.Z*forward.Z), forward)
-			step, maxDist := float64(gridSize)/2, 50.0 // larger step, faster
-			var hit r3.Vec
-			placed := false
-
-			for d := 0.0; d < maxDist; d += step {
-				p := r3.Add(renderer.cPOS, r3.Scale(d, forwardNorm))
-				snap := r3.Vec{
-					X: math.Floor(p.X/float64(gridSize)) * float64(gridSize),
-					Y: math.Floor(p.Y/float64(gridSize)) * float64(gridSize),
-					Z: math.Floor(p.Z/float64(gridSize)) * float64(gridSize),
-				}
-
-				// Baseplate: only if y == 0 exactly
-				if snap.Y <= 0 || snap.Y >= 1 {
-					hit = snap
-					placed = true
-					break
-				}
-
-				// Check if ray hits an existing block
-				var hitBlock r3.Vec
-				blockExists := false
-				for _, b := range placedBlocks {
-					if b == snap {
-						blockExists = true
-						hitBlock = b
-						break
-					}
-				}
-
-				if blockExists {
-					// Determine which face we hit relative to block
-					localPos := r3.Sub(p, hitBlock)
-					offset := r3.Vec{}
-					if math.Abs(localPos.X) > math.Abs(localPos.Y) && math.Abs(localPos.X) > math.Abs(localPos.Z) {
-						if localPos.X > 0 {
-							offset.X = float64(gridSize)
-						} else {
-							offset.X = -float64(gridSize)
-						}
-					} else if math.Abs(localPos.Y) > math.Abs(localPos.Z) {
-						if localPos.Y > 0 {
-							offset.Y = float64(gridSize)
-						} else {
-							offset.Y = -float64(gridSize)
-						}
-					} else {
-						if localPos.Z > 0 {
-							offset.Z = float64(gridSize)
-						} else {
-							offset.Z = -float64(gridSize)
-						}
-					}
-					hit = r3.Add(hitBlock, offset)
-					placed = true
-					break
-				}
+			if hit, ok := renderer.CastVoxelRay(renderer.cPOS, forward); ok {
+				blockPos := r3.Scale(renderer.GridSize, r3.Vec{X: float64(hit.Block[0]), Y: float64(hit.Block[1]), Z: float64(hit.Block[2])})
+				placePos := r3.Add(blockPos, r3.Scale(renderer.GridSize, hit.Normal))
+				renderer.PlaceBlock(placePos, blockColors[selected])
 			}
+		}
+		if win.JustPressed(pixelgl.MouseButtonRight) && mouseGrabbed && !firstClickAfterFocus {
+			if hit, ok := renderer.CastVoxelRay(renderer.cPOS, forward); ok && !hit.Ground {
+				blockPos := r3.Scale(renderer.GridSize, r3.Vec{X: float64(hit.Block[0]), Y: float64(hit.Block[1]), Z: float64(hit.Block[2])})
+				renderer.RemoveBlock(blockPos)
+			}
+		}
 
-			// Prevent overlapping block
-			if placed {
-				overlap := false
-				for _, b := range placedBlocks {
-					if b == hit {
-						overlap = true
-						break
-					}
-				}
-				if !overlap {
-					placedBlocks = append(placedBlocks, hit)
-					cube := renderer.NewCube([]color.Color{blockColors[selected]}, hit, r3.Vec{}, float64(gridSize), float64(gridSize), float64(gridSize), true)
-					renderer.Render3D = append(renderer.Render3D, cube...)
-				}
+		if win.JustPressed(pixelgl.KeyF5) {
+			if err := renderer.SaveOBJ("scene.obj"); err != nil {
+				fmt.Println("SaveOBJ:", err)
+			}
+		}
+		if win.JustPressed(pixelgl.KeyF9) {
+			if err := renderer.LoadOBJ("scene.obj"); err != nil {
+				fmt.Println("LoadOBJ:", err)
 			}
 		}
 
@@ -356,7 +1409,7 @@ func run() {
 		imd.Draw(win)
 
 		txt.Clear()
-		fmt.Fprintf(txt, "Selected: %d\nPlaced: %d", selected, len(placedBlocks))
+		fmt.Fprintf(txt, "Selected: %d\nPlaced: %d\nVisible: %d/%d", selected, len(renderer.PlacedBlocks), renderer.VisibleCount, renderer.TotalCount)
 		txt.Draw(win, pixel.IM)
 
 		win.Update()